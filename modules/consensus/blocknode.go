@@ -0,0 +1,36 @@
+package consensus
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// processedBlock is a copy of a block along with the block-tree bookkeeping
+// and diffs needed to apply, revert, and rank it against competing forks.
+// Maintenance routines append to the Diffs/event slices below as they run;
+// once a block has been fully applied, those slices, apart from
+// SiafundOutputDiffs, are what gets reported to subscribers as a
+// modules.ConsensusChange.
+type processedBlock struct {
+	Block  types.Block
+	Height types.BlockHeight
+
+	Parent   types.BlockID
+	Children []types.BlockID
+
+	Depth       types.Target
+	ChildTarget types.Target
+
+	// DiffsGenerated is true once this block's diffs have been computed and
+	// applied to the consensus set, so that the block can be safely
+	// reverted by reversing those diffs rather than recomputing them.
+	DiffsGenerated bool
+
+	SiacoinOutputDiffs        []modules.SiacoinOutputDiff
+	FileContractDiffs         []modules.FileContractDiff
+	SiafundOutputDiffs        []modules.SiafundOutputDiff
+	DelayedSiacoinOutputDiffs []modules.DelayedSiacoinOutputDiff
+
+	MaturedPayouts []modules.MaturedPayout
+	MissedProofs   []modules.MissedProofEvent
+}
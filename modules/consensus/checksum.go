@@ -0,0 +1,89 @@
+package consensus
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// errChecksumHeightMismatch is returned by Checksum when the requested
+// height is not the consensus set's current height. Only the current
+// height's buckets are on disk to checksum, so any other height cannot be
+// served.
+var errChecksumHeightMismatch = errors.New("checksum requested for a height other than the consensus set's current height")
+
+// checksumBuckets are the buckets that exist exactly once per consensus
+// set, as opposed to the per-height prefix_dsco and prefix_fcex buckets.
+var checksumBuckets = [][]byte{
+	BlockPath,
+	SiacoinOutputs,
+	FileContracts,
+	SiafundOutputs,
+	SiafundPool,
+}
+
+// Checksum returns the merkle root of the consensus database as it exists
+// at 'height'. It walks the constant buckets plus every prefixDSCO and
+// prefixFCEX bucket, in byte-sorted order, pushing each key and value into
+// a merkle tree. Two nodes that report different checksums for the same
+// height have diverged somewhere in their consensus sets.
+func (cs *ConsensusSet) Checksum(height types.BlockHeight) (checksum crypto.Hash, err error) {
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		currentHeight, err := dbBlockHeight(tx)
+		if err != nil {
+			return err
+		}
+		if height != currentHeight {
+			return errChecksumHeightMismatch
+		}
+
+		tree := crypto.NewTree()
+		for _, bucketName := range checksumBuckets {
+			b := tx.Bucket(bucketName)
+			if b == nil {
+				continue
+			}
+			if err := pushBucket(tree, b); err != nil {
+				return err
+			}
+		}
+
+		var prefixed [][]byte
+		err = tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if bytes.HasPrefix(name, prefix_dsco) || bytes.HasPrefix(name, prefix_fcex) {
+				prefixed = append(prefixed, append([]byte(nil), name...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		sort.Slice(prefixed, func(i, j int) bool {
+			return bytes.Compare(prefixed[i], prefixed[j]) < 0
+		})
+		for _, name := range prefixed {
+			if err := pushBucket(tree, tx.Bucket(name)); err != nil {
+				return err
+			}
+		}
+
+		checksum = tree.Root()
+		return nil
+	})
+	return checksum, err
+}
+
+// pushBucket pushes every key and value in b into tree, in the bucket's
+// natural byte-sorted key order.
+func pushBucket(tree *crypto.MerkleTree, b *bolt.Bucket) error {
+	return b.ForEach(func(k, v []byte) error {
+		tree.Push(k)
+		tree.Push(v)
+		return nil
+	})
+}
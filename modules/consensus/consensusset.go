@@ -0,0 +1,105 @@
+package consensus
+
+import (
+	"path/filepath"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/sync"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// ConsensusSet holds the current state of consensus validation, the
+// on-disk block database, and the modules that have subscribed to
+// consensus changes.
+type ConsensusSet struct {
+	db *bolt.DB
+
+	// gateway is used to request blocks from peers and to broadcast newly
+	// accepted blocks during synchronization.
+	gateway modules.Gateway
+
+	// bootstrap indicates whether the consensus set should use gateway to
+	// bootstrap its block history from peers on startup, rather than
+	// trusting only the blocks it already has on disk.
+	bootstrap bool
+
+	// rootTarget and rootDepth are the target and depth of the genesis
+	// block, the values every block-tree computation is anchored against.
+	rootTarget types.Target
+	rootDepth  types.Target
+
+	// mode controls whether DSCO/FCEX buckets orphaned by reorgs are kept
+	// forever (ModeFull) or swept once they are more than pruneRetention
+	// blocks behind the current height (ModePruned).
+	mode Mode
+
+	subscribers   []modules.ConsensusSetSubscriber
+	changes       []modules.ConsensusChange
+	changeHeights map[modules.ConsensusChangeID]types.BlockHeight
+
+	log *persist.Logger
+	tg  sync.ThreadGroup
+
+	mu sync.RWMutex
+}
+
+// New returns a ConsensusSet that keeps its database in persistDir and
+// uses gateway to synchronize with peers. If bootstrap is true, the
+// consensus set fetches blocks from gateway's peers until it has caught up
+// with the rest of the network. mode selects whether DSCO/FCEX buckets
+// orphaned by reorgs are kept forever (ModeFull) or swept once they are
+// more than pruneRetention blocks behind the current height (ModePruned).
+func New(gateway modules.Gateway, bootstrap bool, persistDir string, mode Mode) (*ConsensusSet, error) {
+	db, err := bolt.Open(filepath.Join(persistDir, "consensus.db"), 0660, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &ConsensusSet{
+		db:            db,
+		gateway:       gateway,
+		bootstrap:     bootstrap,
+		mode:          mode,
+		changeHeights: make(map[modules.ConsensusChangeID]types.BlockHeight),
+	}
+	if cs.mode == ModePruned {
+		go cs.threadedPruneLoop()
+	}
+	return cs, nil
+}
+
+// Close signals every background goroutine started by the ConsensusSet to
+// stop, waits for them to exit, and closes the consensus database.
+func (cs *ConsensusSet) Close() error {
+	if err := cs.tg.Stop(); err != nil {
+		return err
+	}
+	return cs.db.Close()
+}
+
+// Height returns the current height of the consensus set, derived from the
+// number of blocks recorded in the BlockPath bucket.
+func (cs *ConsensusSet) Height() (height types.BlockHeight) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		var err error
+		height, err = dbBlockHeight(tx)
+		return err
+	})
+	return height
+}
+
+// dbBlockHeight returns the height of the block most recently recorded in
+// the BlockPath bucket, i.e. the consensus set's current height.
+func dbBlockHeight(tx *bolt.Tx) (types.BlockHeight, error) {
+	b := tx.Bucket(BlockPath)
+	if b == nil || b.Stats().KeyN == 0 {
+		return 0, nil
+	}
+	return types.BlockHeight(b.Stats().KeyN - 1), nil
+}
@@ -0,0 +1,67 @@
+package consensus
+
+import (
+	"github.com/boltdb/bolt"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// payoutSourcesBucket remembers, for every delayed siacoin output created by
+// a miner payout, a recurring subsidy or a missed storage proof that has not
+// yet matured, why it was created. Outputs with no entry are reported as
+// PayoutSourceValid. applyMaturedSiacoinOutputs consults
+// it to fill out the Source and OriginContract fields of the
+// modules.MaturedPayout it reports for the output, then forgets the entry.
+var payoutSourcesBucket = []byte("PayoutSources")
+
+// payoutSource is the value stored in payoutSourcesBucket, keyed by
+// types.SiacoinOutputID.
+type payoutSource struct {
+	Source         modules.PayoutSource
+	OriginContract types.FileContractID
+}
+
+// recordPayoutSource remembers why the delayed siacoin output 'id' was
+// created, so that applyMaturedSiacoinOutputs can report it correctly once
+// the output matures.
+func recordPayoutSource(tx *bolt.Tx, id types.SiacoinOutputID, source modules.PayoutSource, originContract types.FileContractID) error {
+	b, err := tx.CreateBucketIfNotExists(payoutSourcesBucket)
+	if err != nil {
+		return err
+	}
+	return b.Put(id[:], encoding.Marshal(payoutSource{source, originContract}))
+}
+
+// forgetPayoutSource removes the payoutSourcesBucket entry recorded for
+// the delayed siacoin output 'id', without reporting it as matured. Revert
+// handlers call this when the block that recorded the entry is removed
+// from the consensus set before its output ever matures, so that a reorg
+// can't leak an entry into payoutSourcesBucket forever.
+func forgetPayoutSource(tx *bolt.Tx, id types.SiacoinOutputID) error {
+	b, err := tx.CreateBucketIfNotExists(payoutSourcesBucket)
+	if err != nil {
+		return err
+	}
+	return b.Delete(id[:])
+}
+
+// consumePayoutSource looks up and forgets why the delayed siacoin output
+// 'id' was created. If no entry is found, the output is a matured contract
+// payout from a valid (or uncontested) storage proof.
+func consumePayoutSource(tx *bolt.Tx, id types.SiacoinOutputID) (payoutSource, error) {
+	b, err := tx.CreateBucketIfNotExists(payoutSourcesBucket)
+	if err != nil {
+		return payoutSource{}, err
+	}
+	valBytes := b.Get(id[:])
+	if valBytes == nil {
+		return payoutSource{Source: modules.PayoutSourceValid}, nil
+	}
+	var ps payoutSource
+	if err := encoding.Unmarshal(valBytes, &ps); err != nil {
+		return payoutSource{}, err
+	}
+	return ps, b.Delete(id[:])
+}
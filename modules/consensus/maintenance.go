@@ -18,7 +18,8 @@ var (
 	errStorageProofTiming  = errors.New("missed proof triggered for file contract that is not expiring")
 )
 
-// applyMinerPayouts adds a block's miner payouts to the consensus set as
+// applyMinerPayouts adds a block's miner payouts, plus any recurring
+// SubsidySchedule payout active at this height, to the consensus set as
 // delayed siacoin outputs.
 func applyMinerPayouts(tx *bolt.Tx, pb *processedBlock) error {
 	for i := range pb.Block.MinerPayouts {
@@ -35,6 +36,77 @@ func applyMinerPayouts(tx *bolt.Tx, pb *processedBlock) error {
 		if err != nil {
 			return err
 		}
+		err = recordPayoutSource(tx, mpid, modules.PayoutSourceMiner, types.FileContractID{})
+		if err != nil {
+			return err
+		}
+	}
+
+	entry, ok := subsidyAt(pb.Height)
+	if !ok {
+		return nil
+	}
+	subsidyID := subsidyOutputID(pb.Height)
+	dscod := modules.DelayedSiacoinOutputDiff{
+		Direction: modules.DiffApply,
+		ID:        subsidyID,
+		SiacoinOutput: types.SiacoinOutput{
+			Value:      entry.Amount,
+			UnlockHash: entry.UnlockHash,
+		},
+		MaturityHeight: pb.Height + types.MaturityDelay,
+	}
+	pb.DelayedSiacoinOutputDiffs = append(pb.DelayedSiacoinOutputDiffs, dscod)
+	err := commitDelayedSiacoinOutputDiff(tx, dscod, modules.DiffApply)
+	if err != nil {
+		return err
+	}
+	return recordPayoutSource(tx, subsidyID, modules.PayoutSourceSubsidy, types.FileContractID{})
+}
+
+// revertMinerPayouts removes a block's miner payouts, plus any recurring
+// SubsidySchedule payout active at this height, from the consensus set.
+// It is the inverse of applyMinerPayouts, called when pb is reverted
+// (typically by a reorg) before its delayed outputs have matured. Forgetting
+// the payoutSourcesBucket entries here keeps that bucket from leaking an
+// entry for every payout a reverted block ever recorded.
+func revertMinerPayouts(tx *bolt.Tx, pb *processedBlock) error {
+	entry, ok := subsidyAt(pb.Height)
+	if ok {
+		subsidyID := subsidyOutputID(pb.Height)
+		dscod := modules.DelayedSiacoinOutputDiff{
+			Direction: modules.DiffApply,
+			ID:        subsidyID,
+			SiacoinOutput: types.SiacoinOutput{
+				Value:      entry.Amount,
+				UnlockHash: entry.UnlockHash,
+			},
+			MaturityHeight: pb.Height + types.MaturityDelay,
+		}
+		err := commitDelayedSiacoinOutputDiff(tx, dscod, modules.DiffRevert)
+		if err != nil {
+			return err
+		}
+		if err := forgetPayoutSource(tx, subsidyID); err != nil {
+			return err
+		}
+	}
+
+	for i := range pb.Block.MinerPayouts {
+		mpid := pb.Block.MinerPayoutID(uint64(i))
+		dscod := modules.DelayedSiacoinOutputDiff{
+			Direction:      modules.DiffApply,
+			ID:             mpid,
+			SiacoinOutput:  pb.Block.MinerPayouts[i],
+			MaturityHeight: pb.Height + types.MaturityDelay,
+		}
+		err := commitDelayedSiacoinOutputDiff(tx, dscod, modules.DiffRevert)
+		if err != nil {
+			return err
+		}
+		if err := forgetPayoutSource(tx, mpid); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -76,7 +148,24 @@ func applyMaturedSiacoinOutputs(tx *bolt.Tx, pb *processedBlock) error {
 			MaturityHeight: pb.Height,
 		}
 		pb.DelayedSiacoinOutputDiffs = append(pb.DelayedSiacoinOutputDiffs, dscod)
-		return commitDelayedSiacoinOutputDiff(tx, dscod, modules.DiffApply)
+		err = commitDelayedSiacoinOutputDiff(tx, dscod, modules.DiffApply)
+		if err != nil {
+			return err
+		}
+
+		// Report why this output matured so that subscribers don't have to
+		// re-derive it.
+		ps, err := consumePayoutSource(tx, id)
+		if err != nil {
+			return err
+		}
+		pb.MaturedPayouts = append(pb.MaturedPayouts, modules.MaturedPayout{
+			ID:             id,
+			Output:         sco,
+			Source:         ps.Source,
+			OriginContract: ps.OriginContract,
+		})
+		return nil
 	})
 	if err != nil {
 		return err
@@ -100,6 +189,7 @@ func applyTxMissedStorageProof(tx *bolt.Tx, pb *processedBlock, fcid types.FileC
 	}
 
 	// Add all of the outputs in the missed proof outputs to the consensus set.
+	missedOutputIDs := make([]types.SiacoinOutputID, 0, len(fc.MissedProofOutputs))
 	for i, mpo := range fc.MissedProofOutputs {
 		// Sanity check - output should not already exist.
 		spoid := fcid.StorageProofOutputID(types.ProofMissed, uint64(i))
@@ -121,7 +211,16 @@ func applyTxMissedStorageProof(tx *bolt.Tx, pb *processedBlock, fcid types.FileC
 		if err != nil {
 			return err
 		}
+		err = recordPayoutSource(tx, spoid, modules.PayoutSourceMissed, fcid)
+		if err != nil {
+			return err
+		}
+		missedOutputIDs = append(missedOutputIDs, spoid)
 	}
+	pb.MissedProofs = append(pb.MissedProofs, modules.MissedProofEvent{
+		ContractID: fcid,
+		Outputs:    missedOutputIDs,
+	})
 
 	// Remove the file contract from the consensus set and record the diff in
 	// the blockNode.
@@ -134,6 +233,31 @@ func applyTxMissedStorageProof(tx *bolt.Tx, pb *processedBlock, fcid types.FileC
 	return commitFileContractDiff(tx, fcd, modules.DiffApply)
 }
 
+// revertTxMissedStorageProof is the inverse of applyTxMissedStorageProof,
+// called when the file contract expiration it recorded is reverted before
+// its missed-proof outputs have matured. Forgetting the payoutSourcesBucket
+// entries here keeps a reverted expiration from leaking an entry for every
+// missed-proof output it ever recorded.
+func revertTxMissedStorageProof(tx *bolt.Tx, pb *processedBlock, fcid types.FileContractID, fc types.FileContract) error {
+	for i, mpo := range fc.MissedProofOutputs {
+		spoid := fcid.StorageProofOutputID(types.ProofMissed, uint64(i))
+		dscod := modules.DelayedSiacoinOutputDiff{
+			Direction:      modules.DiffApply,
+			ID:             spoid,
+			SiacoinOutput:  mpo,
+			MaturityHeight: pb.Height + types.MaturityDelay,
+		}
+		err := commitDelayedSiacoinOutputDiff(tx, dscod, modules.DiffRevert)
+		if err != nil {
+			return err
+		}
+		if err := forgetPayoutSource(tx, spoid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // applyFileContractMaintenance looks for all of the file contracts that have
 // expired without an appropriate storage proof, and calls 'applyMissedProof'
 // for the file contract.
@@ -152,14 +276,14 @@ func applyFileContractMaintenance(tx *bolt.Tx, pb *processedBlock) error {
 	if err != nil {
 		return err
 	}
-	return nil
-	// return tx.DeleteBucket(fceBucketID)
+	return tx.DeleteBucket(fceBucketID)
 }
 
 // applyMaintenance applies block-level alterations to the consensus set.
 // Maintenance is applied after all of the transcations for the block have been
-// applied.
-func applyMaintenance(tx *bolt.Tx, pb *processedBlock) error {
+// applied. Once every maintenance routine has recorded its diffs and events
+// on pb, the resulting consensus change is sent to cs's subscribers.
+func applyMaintenance(cs *ConsensusSet, tx *bolt.Tx, pb *processedBlock) error {
 	err := applyMinerPayouts(tx, pb)
 	if err != nil {
 		return err
@@ -168,5 +292,10 @@ func applyMaintenance(tx *bolt.Tx, pb *processedBlock) error {
 	if err != nil {
 		return err
 	}
-	return applyFileContractMaintenance(tx, pb)
+	err = applyFileContractMaintenance(tx, pb)
+	if err != nil {
+		return err
+	}
+	cs.updateSubscribers(pb)
+	return nil
 }
@@ -0,0 +1,181 @@
+package consensus
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// Mode selects whether a ConsensusSet sweeps up orphaned DSCO/FCEX buckets
+// left behind by reorgs.
+//
+// In normal operation a DSCO/FCEX bucket is deleted synchronously the
+// instant its own height is reached (deleteDSCOBucket in
+// applyMaturedSiacoinOutputs, tx.DeleteBucket in
+// applyFileContractMaintenance), so once the chain has passed a height its
+// bucket is already gone - there is nothing left for a background sweep to
+// find there. But if the block that created a bucket is reverted by a
+// reorg before that height is ever reached again on the new, winning fork,
+// the synchronous delete never runs, and the bucket is orphaned for good.
+// ModePruned exists to reclaim exactly that leftover disk space.
+type Mode int
+
+// The two supported modes. ModeFull never sweeps orphaned buckets, which is
+// what every ConsensusSet did before Pruned mode was added. ModePruned
+// periodically sweeps buckets older than pruneRetention that a reorg left
+// behind.
+const (
+	ModeFull Mode = iota
+	ModePruned
+)
+
+// ErrPrunedHistory is returned when a caller asks to resume from a
+// ChangeID whose height is older than the consensus set's prunedBefore
+// height, the height below which Pruned mode has swept DSCO/FCEX buckets.
+// Callers should resync from a new subscription or a snapshot instead.
+var ErrPrunedHistory = errors.New("requested change id predates this consensus set's pruned history")
+
+// pruneRetention is how far behind the current height a DSCO/FCEX bucket
+// must be before Pruned mode considers it an orphan eligible for sweeping,
+// rather than a bucket that might still belong to a fork close enough to
+// the current tip to win a reorg. It is deliberately generous relative to
+// types.MaturityDelay so that a fork has to be thoroughly abandoned before
+// its leftover buckets are swept.
+const pruneRetention = 2 * types.MaturityDelay
+
+// pruneInterval is how often Pruned mode checks for newly-compactable
+// history.
+const pruneInterval = 10 * time.Minute
+
+var (
+	bucketMetadata   = []byte("Metadata")
+	metaPrunedBefore = []byte("PrunedBefore")
+)
+
+// prunedBefore returns the height before which Pruned mode has already
+// compacted historical buckets, or 0 if nothing has been pruned yet.
+func prunedBefore(tx *bolt.Tx) (types.BlockHeight, error) {
+	b := tx.Bucket(bucketMetadata)
+	if b == nil {
+		return 0, nil
+	}
+	valBytes := b.Get(metaPrunedBefore)
+	if valBytes == nil {
+		return 0, nil
+	}
+	var height types.BlockHeight
+	err := encoding.Unmarshal(valBytes, &height)
+	return height, err
+}
+
+// setPrunedBefore records the rolling height before which Pruned mode has
+// compacted historical buckets.
+func setPrunedBefore(tx *bolt.Tx, height types.BlockHeight) error {
+	b, err := tx.CreateBucketIfNotExists(bucketMetadata)
+	if err != nil {
+		return err
+	}
+	return b.Put(metaPrunedBefore, encoding.Marshal(height))
+}
+
+// bucketHeight extracts the trailing, fixed-width encoded BlockHeight from
+// a prefix_dsco or prefix_fcex bucket name.
+func bucketHeight(name []byte) (types.BlockHeight, bool) {
+	const heightLen = 8
+	if len(name) < heightLen {
+		return 0, false
+	}
+	var height types.BlockHeight
+	if err := encoding.Unmarshal(name[len(name)-heightLen:], &height); err != nil {
+		return 0, false
+	}
+	return height, true
+}
+
+// managedPruneOnce sweeps every prefix_dsco/prefix_fcex bucket whose height is
+// more than pruneRetention blocks behind the consensus set's current
+// height. On the canonical chain such buckets are always deleted
+// synchronously as soon as their height is reached, so anything this sweep
+// finds is an orphan a reorg left behind on an abandoned fork. Advances
+// prunedBefore accordingly. It is a no-op unless the ConsensusSet was
+// created in ModePruned.
+func (cs *ConsensusSet) managedPruneOnce() error {
+	if cs.mode != ModePruned {
+		return nil
+	}
+
+	height := cs.Height()
+	if height <= pruneRetention {
+		return nil
+	}
+	cutoff := height - pruneRetention
+
+	return cs.db.Update(func(tx *bolt.Tx) error {
+		before, err := prunedBefore(tx)
+		if err != nil {
+			return err
+		}
+
+		var stale [][]byte
+		err = tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if !bytes.HasPrefix(name, prefix_dsco) && !bytes.HasPrefix(name, prefix_fcex) {
+				return nil
+			}
+			h, ok := bucketHeight(name)
+			if !ok || h < before || h >= cutoff {
+				return nil
+			}
+			stale = append(stale, append([]byte(nil), name...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range stale {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return setPrunedBefore(tx, cutoff)
+	})
+}
+
+// threadedPruneLoop periodically compacts historical buckets while the
+// consensus set is running in ModePruned. It exits once the thread group is
+// stopped.
+func (cs *ConsensusSet) threadedPruneLoop() {
+	if err := cs.tg.Add(); err != nil {
+		return
+	}
+	defer cs.tg.Done()
+
+	for {
+		select {
+		case <-cs.tg.StopChan():
+			return
+		case <-time.After(pruneInterval):
+			if err := cs.managedPruneOnce(); err != nil {
+				cs.log.Println("ERROR: consensus pruning failed:", err)
+			}
+		}
+	}
+}
+
+// checkPrunedHistory returns ErrPrunedHistory if changeHeight is older than
+// the height Pruned mode has already compacted away. ConsensusSetSubscribe
+// calls this before replaying diffs for a subscriber's requested ChangeID.
+func (cs *ConsensusSet) checkPrunedHistory(tx *bolt.Tx, changeHeight types.BlockHeight) error {
+	before, err := prunedBefore(tx)
+	if err != nil {
+		return err
+	}
+	if changeHeight < before {
+		return ErrPrunedHistory
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package consensus
+
+import (
+	"errors"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// consensusChange builds the modules.ConsensusChange that subscribers
+// receive for a processed block, from the diffs that maintenance recorded
+// on pb while applying the block.
+func consensusChange(pb *processedBlock) modules.ConsensusChange {
+	return modules.ConsensusChange{
+		ID:                        modules.ConsensusChangeID(pb.Block.ID()),
+		AppliedBlocks:             []types.Block{pb.Block},
+		SiacoinOutputDiffs:        pb.SiacoinOutputDiffs,
+		FileContractDiffs:         pb.FileContractDiffs,
+		DelayedSiacoinOutputDiffs: pb.DelayedSiacoinOutputDiffs,
+		MaturedPayouts:            pb.MaturedPayouts,
+		MissedProofs:              pb.MissedProofs,
+	}
+}
+
+// updateSubscribers builds the consensus change for pb, remembers it so
+// that future subscribers can resume from it, and forwards it to every
+// subscriber currently registered with the consensus set.
+func (cs *ConsensusSet) updateSubscribers(pb *processedBlock) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cc := consensusChange(pb)
+	cs.changes = append(cs.changes, cc)
+	cs.changeHeights[cc.ID] = pb.Height
+
+	for _, subscriber := range cs.subscribers {
+		subscriber.ProcessConsensusChange(cc)
+	}
+}
+
+// errUnknownConsensusChangeID is returned by ConsensusSetSubscribe when
+// 'start' is not the zero ConsensusChangeID and isn't one this consensus
+// set remembers. cs.changes/cs.changeHeights are in-memory only and start
+// out empty after every restart, so a missing entry could mean either an
+// invalid ID or a perfectly valid one the process simply doesn't remember
+// yet. Treating "not found" as "replay everything" would silently hand a
+// resuming subscriber an empty replay instead of the blocks it missed, and
+// treating it as "skip the prune guard" would let a subscriber bypass
+// ErrPrunedHistory just by reconnecting. Failing closed avoids both.
+var errUnknownConsensusChangeID = errors.New("consensus set does not recognize the given change id")
+
+// ConsensusSetSubscribe adds a subscriber to the list of subscribers, and
+// sends the subscriber every consensus change that occurred after 'start'.
+// A zero-value start replays every consensus change this consensus set has
+// recorded since it was created, and is not checked against the pruned
+// history. If a non-zero start is older than the consensus set's pruned history, ConsensusSetSubscribe
+// fails with ErrPrunedHistory instead of subscribing, so that the caller
+// knows to resync from a snapshot. If start is unrecognized,
+// ConsensusSetSubscribe fails with errUnknownConsensusChangeID.
+func (cs *ConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var changes []modules.ConsensusChange
+	if start == (modules.ConsensusChangeID{}) {
+		changes = cs.changes
+	} else {
+		startHeight, ok := cs.changeHeights[start]
+		if !ok {
+			return errUnknownConsensusChangeID
+		}
+		err := cs.db.View(func(tx *bolt.Tx) error {
+			return cs.checkPrunedHistory(tx, startHeight)
+		})
+		if err != nil {
+			return err
+		}
+		changes = cs.changesAfter(start)
+	}
+
+	for _, cc := range changes {
+		subscriber.ProcessConsensusChange(cc)
+	}
+	cs.subscribers = append(cs.subscribers, subscriber)
+	return nil
+}
+
+// changesAfter returns every remembered consensus change that occurred
+// after 'start'. It must only be called with a 'start' already confirmed
+// to be present in cs.changeHeights.
+func (cs *ConsensusSet) changesAfter(start modules.ConsensusChangeID) []modules.ConsensusChange {
+	for i, cc := range cs.changes {
+		if cc.ID == start {
+			return cs.changes[i+1:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package consensus
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// subsidyAt returns the types.SubsidyEntry active at 'height' and true, or
+// a zero SubsidyEntry and false if no subsidy is scheduled for that height
+// or the Foundation hardfork has not activated yet.
+func subsidyAt(height types.BlockHeight) (types.SubsidyEntry, bool) {
+	if height < types.FoundationHardforkHeight {
+		return types.SubsidyEntry{}, false
+	}
+	for _, entry := range types.SubsidySchedule {
+		if height >= entry.StartHeight && height < entry.EndHeight {
+			return entry, true
+		}
+	}
+	return types.SubsidyEntry{}, false
+}
+
+// subsidyOutputID derives a deterministic, collision-free ID for the
+// recurring subsidy output at 'height', the same way
+// Block.MinerPayoutID derives IDs for ordinary miner payouts.
+func subsidyOutputID(height types.BlockHeight) types.SiacoinOutputID {
+	return types.SiacoinOutputID(crypto.HashAll("subsidy", height))
+}
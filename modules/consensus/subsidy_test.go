@@ -0,0 +1,173 @@
+package consensus
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// newSubsidyTestConsensusSet returns a bare ConsensusSet backed by its own
+// on-disk database, suitable for exercising applyMinerPayouts and Checksum
+// directly without going through New (which also wires up a gateway and a
+// prune loop that these tests have no use for).
+func newSubsidyTestConsensusSet(t *testing.T) *ConsensusSet {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "consensus.db"), 0660, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &ConsensusSet{
+		db:            db,
+		changeHeights: make(map[modules.ConsensusChangeID]types.BlockHeight),
+	}
+}
+
+// applySubsidyAtHeight records cs's current height as 'height' and applies
+// a block's worth of miner payouts at that height, so that cs.Checksum
+// reflects whatever delayed subsidy output (if any) was active there.
+func applySubsidyAtHeight(t *testing.T, cs *ConsensusSet, height types.BlockHeight) {
+	err := cs.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(BlockPath)
+		if err != nil {
+			return err
+		}
+		for i := types.BlockHeight(0); i <= height; i++ {
+			if err := b.Put(encoding.Marshal(i), encoding.Marshal(i)); err != nil {
+				return err
+			}
+		}
+		return applyMinerPayouts(tx, &processedBlock{Height: height})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestChecksumMatchesAcrossNodesWithSubsidyActive verifies that two
+// independent consensus sets running the same types.SubsidySchedule derive
+// identical delayed subsidy outputs - and therefore identical checksums -
+// at the same post-hardfork height. This is the "with the subsidy active"
+// half of the consensus-rule guarantee requested for the recurring
+// subsidy: nodes that agree on the schedule must never diverge.
+func TestChecksumMatchesAcrossNodesWithSubsidyActive(t *testing.T) {
+	entry := types.SubsidyEntry{
+		StartHeight: types.FoundationHardforkHeight,
+		EndHeight:   types.FoundationHardforkHeight + 1000,
+		Amount:      types.NewCurrency64(1),
+		UnlockHash:  types.UnlockHash{1},
+	}
+	orig := types.SubsidySchedule
+	types.SubsidySchedule = []types.SubsidyEntry{entry}
+	defer func() { types.SubsidySchedule = orig }()
+
+	nodeA := newSubsidyTestConsensusSet(t)
+	nodeB := newSubsidyTestConsensusSet(t)
+	applySubsidyAtHeight(t, nodeA, types.FoundationHardforkHeight)
+	applySubsidyAtHeight(t, nodeB, types.FoundationHardforkHeight)
+
+	checksumA, err := nodeA.Checksum(types.FoundationHardforkHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksumB, err := nodeB.Checksum(types.FoundationHardforkHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksumA != checksumB {
+		t.Fatal("nodes running the same SubsidySchedule diverged at the hardfork height")
+	}
+}
+
+// TestChecksumDivergesAcrossNodesWithMismatchedSubsidy verifies the
+// opposite: a node with an active types.SubsidySchedule and a node with no
+// schedule at all must diverge at the hardfork height, since only one of
+// them credits the recurring subsidy output. This is what guards against a
+// node silently running with the wrong schedule and forking away from the
+// rest of the network without any checksum mismatch to flag it.
+func TestChecksumDivergesAcrossNodesWithMismatchedSubsidy(t *testing.T) {
+	entry := types.SubsidyEntry{
+		StartHeight: types.FoundationHardforkHeight,
+		EndHeight:   types.FoundationHardforkHeight + 1000,
+		Amount:      types.NewCurrency64(1),
+		UnlockHash:  types.UnlockHash{1},
+	}
+	orig := types.SubsidySchedule
+
+	nodeA := newSubsidyTestConsensusSet(t)
+	types.SubsidySchedule = []types.SubsidyEntry{entry}
+	applySubsidyAtHeight(t, nodeA, types.FoundationHardforkHeight)
+
+	nodeB := newSubsidyTestConsensusSet(t)
+	types.SubsidySchedule = nil
+	applySubsidyAtHeight(t, nodeB, types.FoundationHardforkHeight)
+
+	types.SubsidySchedule = orig
+
+	checksumA, err := nodeA.Checksum(types.FoundationHardforkHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksumB, err := nodeB.Checksum(types.FoundationHardforkHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksumA == checksumB {
+		t.Fatal("nodes that disagree about the active SubsidySchedule must not produce the same checksum")
+	}
+}
+
+// TestSubsidyAtHardforkActivation verifies that a types.SubsidySchedule
+// entry is only honored from types.FoundationHardforkHeight onward. This is
+// the rule that keeps a chain's checksum identical across nodes before the
+// fork height: applyMinerPayouts consults subsidyAt for every block, so two
+// nodes that disagree about whether the fork is active would derive
+// different delayed siacoin outputs - and therefore different Checksum
+// results - at the same height.
+func TestSubsidyAtHardforkActivation(t *testing.T) {
+	entry := types.SubsidyEntry{
+		StartHeight: types.FoundationHardforkHeight,
+		EndHeight:   types.FoundationHardforkHeight + 1000,
+		Amount:      types.NewCurrency64(1),
+		UnlockHash:  types.UnlockHash{1},
+	}
+	orig := types.SubsidySchedule
+	types.SubsidySchedule = []types.SubsidyEntry{entry}
+	defer func() { types.SubsidySchedule = orig }()
+
+	if _, active := subsidyAt(types.FoundationHardforkHeight - 1); active {
+		t.Fatal("subsidy must not be active before the hardfork height")
+	}
+	got, active := subsidyAt(types.FoundationHardforkHeight)
+	if !active {
+		t.Fatal("subsidy must be active at the hardfork height")
+	}
+	if got.StartHeight != entry.StartHeight || got.EndHeight != entry.EndHeight ||
+		got.Amount.Cmp(entry.Amount) != 0 || got.UnlockHash != entry.UnlockHash {
+		t.Fatalf("expected %v, got %v", entry, got)
+	}
+	if _, active := subsidyAt(entry.EndHeight); active {
+		t.Fatal("subsidy must not be active once its range ends")
+	}
+}
+
+// TestSubsidyOutputIDDeterministic verifies that subsidyOutputID derives
+// the same output ID for the same height every time - so that two nodes
+// with the subsidy active produce identical delayed outputs, and therefore
+// identical checksums - and a different ID for a different height, so the
+// subsidy output never collides with another delayed output.
+func TestSubsidyOutputIDDeterministic(t *testing.T) {
+	id1 := subsidyOutputID(types.FoundationHardforkHeight)
+	id2 := subsidyOutputID(types.FoundationHardforkHeight)
+	if id1 != id2 {
+		t.Fatal("subsidyOutputID is not deterministic")
+	}
+	id3 := subsidyOutputID(types.FoundationHardforkHeight + 1)
+	if id1 == id3 {
+		t.Fatal("subsidyOutputID collided across heights")
+	}
+}
@@ -0,0 +1,83 @@
+package modules
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// ConsensusChangeID uniquely identifies a ConsensusChange. Subscribers pass
+// the ID of the last change they processed to ConsensusSetSubscribe to
+// resume from where they left off.
+type ConsensusChangeID crypto.Hash
+
+// ConsensusSetSubscriber is the interface that modules implement to receive
+// ConsensusChanges from a ConsensusSet as blocks are applied.
+type ConsensusSetSubscriber interface {
+	// ProcessConsensusChange sends a consensus update to a module through
+	// a function call. Updates will always be sent in the correct order.
+	ProcessConsensusChange(ConsensusChange)
+}
+
+// ConsensusChange summarizes the diffs and events that a single block
+// caused when it was applied to the consensus set. ConsensusSetSubscribe
+// delivers one of these for every block a subscriber needs to catch up on.
+type ConsensusChange struct {
+	// ID identifies this consensus change. Passing it to
+	// ConsensusSetSubscribe resumes a subscription from this point.
+	ID ConsensusChangeID
+
+	// AppliedBlocks contains the blocks that were applied to produce this
+	// consensus change.
+	AppliedBlocks []types.Block
+
+	SiacoinOutputDiffs        []SiacoinOutputDiff
+	FileContractDiffs         []FileContractDiff
+	DelayedSiacoinOutputDiffs []DelayedSiacoinOutputDiff
+
+	// MaturedPayouts and MissedProofs let subscribers attribute coin
+	// movements to a miner payout, a matured contract payout, or a missed
+	// storage proof without re-deriving the relevant output IDs.
+	MaturedPayouts []MaturedPayout
+	MissedProofs   []MissedProofEvent
+}
+
+// PayoutSource indicates why a MaturedPayout was credited to the consensus
+// set.
+type PayoutSource uint8
+
+// The types of payout a MaturedPayout can report.
+const (
+	// PayoutSourceMiner indicates the payout is a block's miner subsidy.
+	PayoutSourceMiner PayoutSource = iota
+
+	// PayoutSourceValid indicates the payout is a file contract's payout
+	// for a valid (or missing, uncontested) storage proof.
+	PayoutSourceValid
+
+	// PayoutSourceMissed indicates the payout is a file contract's payout
+	// for a missed storage proof.
+	PayoutSourceMissed
+
+	// PayoutSourceSubsidy indicates the payout is a recurring
+	// foundation/dev-fund payout from types.SubsidySchedule, not a miner's
+	// block reward.
+	PayoutSourceSubsidy
+)
+
+// MaturedPayout reports a siacoin output that became spendable because a
+// delayed siacoin output matured. Subscribers can use it to attribute the
+// output to a miner payout, a recurring subsidy, a successful storage
+// proof, or a missed one, without reverse-engineering the output's ID.
+type MaturedPayout struct {
+	ID             types.SiacoinOutputID
+	Output         types.SiacoinOutput
+	Source         PayoutSource
+	OriginContract types.FileContractID
+}
+
+// MissedProofEvent reports the payouts released when a file contract
+// expired without a valid storage proof.
+type MissedProofEvent struct {
+	ContractID types.FileContractID
+	Outputs    []types.SiacoinOutputID
+}
@@ -0,0 +1,12 @@
+package modules
+
+// NetAddress is the address of a peer on the Sia network.
+type NetAddress string
+
+// Gateway is the subset of the gateway module's interface that the
+// consensus set needs in order to synchronize with peers and broadcast
+// newly accepted blocks.
+type Gateway interface {
+	Synchronize(peer NetAddress) error
+	Broadcast(name string, obj interface{}, peers []NetAddress)
+}
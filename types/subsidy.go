@@ -0,0 +1,22 @@
+package types
+
+// SubsidyEntry describes a recurring block subsidy, analogous to a
+// foundation or dev-fund payout, that is active for a contiguous range of
+// block heights.
+type SubsidyEntry struct {
+	StartHeight BlockHeight
+	EndHeight   BlockHeight
+	Amount      Currency
+	UnlockHash  UnlockHash
+}
+
+// SubsidySchedule lists every recurring block subsidy that has ever been
+// defined for this chain. At most one entry is expected to be active for
+// any given height; leave it empty to disable the feature entirely.
+var SubsidySchedule []SubsidyEntry
+
+// FoundationHardforkHeight is the height at which nodes start validating
+// the recurring SubsidySchedule payouts. Before this height, blocks are
+// validated exactly as they always have been, so that a chain already in
+// flight keeps validating for nodes that haven't upgraded yet.
+const FoundationHardforkHeight BlockHeight = 100e3
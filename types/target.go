@@ -0,0 +1,6 @@
+package types
+
+// Target is compared against a block's ID to determine whether the block
+// is valid. A block is valid only if its ID, interpreted as a big-endian
+// integer, is less than or equal to the Target in effect for it.
+type Target [32]byte